@@ -0,0 +1,70 @@
+package serve
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hcnet/go/keypair"
+	"github.com/hcnet/go/strkey"
+)
+
+// logStartupBanner logs, once, a structured summary of the trust
+// configuration a running instance will accept: which challenge signers it
+// trusts, which keys it signs and publishes tokens with, and which Aurora
+// instance it verifies accounts against. This gives operators a single log
+// line to audit what a deployment actually accepts, and fails fast if
+// Aurora's network passphrase does not match what this instance was
+// configured with.
+func logStartupBanner(opts Options, signingKeys *signingKeySet) error {
+	var signingAddresses []string
+	for _, addr := range opts.SigningAddresses {
+		signingAddresses = append(signingAddresses, fmt.Sprintf("%s(%s)", addr.Address(), ed25519Fingerprint(addr)))
+	}
+
+	var jwks []string
+	for _, k := range signingKeys.JWKS(time.Now()).Keys {
+		thumbprint, err := k.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return fmt.Errorf("computing thumbprint for JWK %q: %w", k.KeyID, err)
+		}
+		jwks = append(jwks, fmt.Sprintf("%s(%s,%s)", k.KeyID, k.Algorithm, base64.RawURLEncoding.EncodeToString(thumbprint)))
+	}
+
+	root, err := opts.AuroraClient.Root()
+	if err != nil {
+		return fmt.Errorf("fetching Aurora root to verify network passphrase: %w", err)
+	}
+	if root.NetworkPassphrase != opts.NetworkPassphrase {
+		return fmt.Errorf("configured network passphrase %q does not match Aurora's %q at %s", opts.NetworkPassphrase, root.NetworkPassphrase, opts.AuroraURL)
+	}
+
+	opts.Logger.
+		WithField("signingAddresses", strings.Join(signingAddresses, ",")).
+		WithField("jwks", strings.Join(jwks, ",")).
+		WithField("issuer", opts.JWTIssuer).
+		WithField("expiresIn", opts.JWTExpiresIn).
+		WithField("domain", opts.Domain).
+		WithField("homeDomains", strings.Join(opts.HomeDomains, ",")).
+		WithField("allowAccountsThatDoNotExist", opts.AllowAccountsThatDoNotExist).
+		WithField("auroraURL", opts.AuroraURL).
+		WithField("networkPassphrase", root.NetworkPassphrase).
+		Info("Auth server trust configuration.")
+
+	return nil
+}
+
+// ed25519Fingerprint returns a hex-encoded SHA-256 fingerprint of a
+// signing address's underlying public key, suitable for logging without
+// leaking anything beyond what the G... address itself already reveals.
+func ed25519Fingerprint(addr *keypair.FromAddress) string {
+	raw, err := strkey.Decode(strkey.VersionByteAccountID, addr.Address())
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return fmt.Sprintf("%x", sum)
+}
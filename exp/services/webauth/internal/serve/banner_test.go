@@ -0,0 +1,23 @@
+package serve
+
+import (
+	"testing"
+
+	"github.com/hcnet/go/keypair"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519FingerprintIsStableAndDistinct(t *testing.T) {
+	kp1, err := keypair.Random()
+	require.NoError(t, err)
+	kp2, err := keypair.Random()
+	require.NoError(t, err)
+
+	addr1 := kp1.FromAddress()
+	addr2 := kp2.FromAddress()
+
+	assert.NotEmpty(t, ed25519Fingerprint(addr1))
+	assert.Equal(t, ed25519Fingerprint(addr1), ed25519Fingerprint(addr1))
+	assert.NotEqual(t, ed25519Fingerprint(addr1), ed25519Fingerprint(addr2))
+}
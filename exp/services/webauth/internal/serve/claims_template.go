@@ -0,0 +1,106 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// claimsTemplateContext is the data made available to a claims template:
+// everything tokenHandler already knows about a verified challenge by the
+// time it is ready to issue a token.
+type claimsTemplateContext struct {
+	AccountID       string
+	HomeDomain      string
+	SigningAddress  string
+	SignersVerified []claimsTemplateSigner
+	AccountExists   bool
+	LowThreshold    int32
+	MedThreshold    int32
+	HighThreshold   int32
+	TransactionHash string
+}
+
+// claimsTemplateSigner is one signer that contributed to meeting the
+// challenge's signature threshold.
+type claimsTemplateSigner struct {
+	Address string
+	Weight  int32
+}
+
+// reservedClaims cannot be set by a claims template: tokenHandler always
+// derives these itself, so a misconfigured template cannot forge a token's
+// issuer or validity period. `sub` is deliberately not reserved, since
+// operators are expected to override it, e.g. to emit a muxed-account
+// encoding instead of the default.
+var reservedClaims = map[string]bool{
+	"iss": true,
+	"iat": true,
+	"exp": true,
+}
+
+// defaultClaimsTemplateText reproduces tokenHandler's behavior from before
+// claims templates existed: no private claims beyond iss/sub/iat/exp.
+const defaultClaimsTemplateText = `{}`
+
+// errClaimsRejected is returned when a claims template deliberately
+// short-circuits issuance by calling the `fail` template function, e.g. to
+// deny an account the template doesn't recognize. tokenHandler treats this
+// distinctly from other template errors: it's an intentional denial, not a
+// misconfiguration, so it results in a 401 rather than a 500.
+type errClaimsRejected struct {
+	Reason string
+}
+
+func (e *errClaimsRejected) Error() string {
+	return fmt.Sprintf("claims template rejected issuance: %s", e.Reason)
+}
+
+// claimsTemplate renders the private claims of an issued JWT from a
+// text/template, letting operators customize token contents per home
+// domain without forking the service.
+type claimsTemplate struct {
+	tmpl *template.Template
+}
+
+func newClaimsTemplate(homeDomain, text string) (*claimsTemplate, error) {
+	tmpl := template.New(homeDomain).Funcs(template.FuncMap{
+		// fail lets a template deliberately reject issuance, e.g.
+		// {{if not .AccountExists}}{{fail "unknown account"}}{{end}}.
+		"fail": func(reason string) (string, error) {
+			return "", &errClaimsRejected{Reason: reason}
+		},
+	})
+	tmpl, err := tmpl.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing claims template for %q: %w", homeDomain, err)
+	}
+	return &claimsTemplate{tmpl: tmpl}, nil
+}
+
+// Claims renders the template against ctx and decodes the result as a set
+// of private claims, rejecting any that collide with a reserved claim. If
+// the template called fail, the returned error is an *errClaimsRejected.
+func (t *claimsTemplate) Claims(ctx claimsTemplateContext) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, ctx); err != nil {
+		var rejected *errClaimsRejected
+		if errors.As(err, &rejected) {
+			return nil, rejected
+		}
+		return nil, fmt.Errorf("executing claims template: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &claims); err != nil {
+		return nil, fmt.Errorf("claims template did not render a JSON object: %w", err)
+	}
+	for name := range claims {
+		if reservedClaims[name] {
+			return nil, fmt.Errorf("claims template may not set reserved claim %q", name)
+		}
+	}
+	return claims, nil
+}
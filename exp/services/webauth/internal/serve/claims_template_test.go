@@ -0,0 +1,95 @@
+package serve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimsTemplateDefault(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", defaultClaimsTemplateText)
+	require.NoError(t, err)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{AccountID: "GABC"})
+	require.NoError(t, err)
+	assert.Empty(t, claims)
+}
+
+func TestClaimsTemplateAddsCustomClaims(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `{"roles": ["user"], "scope": "{{.HomeDomain}}"}`)
+	require.NoError(t, err)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{HomeDomain: "example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", claims["scope"])
+	assert.Equal(t, []interface{}{"user"}, claims["roles"])
+}
+
+func TestClaimsTemplateRejectsReservedClaims(t *testing.T) {
+	for _, reserved := range []string{"iss", "iat", "exp"} {
+		tmpl, err := newClaimsTemplate("example.com", `{"`+reserved+`": "overridden"}`)
+		require.NoError(t, err)
+
+		_, err = tmpl.Claims(claimsTemplateContext{})
+		assert.Error(t, err)
+	}
+}
+
+func TestClaimsTemplateCanOverrideSubject(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `{"sub": "{{.AccountID}}:muxed"}`)
+	require.NoError(t, err)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{AccountID: "GABC"})
+	require.NoError(t, err)
+	assert.Equal(t, "GABC:muxed", claims["sub"])
+}
+
+func TestClaimsTemplateRejectsNonObjectOutput(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `not json`)
+	require.NoError(t, err)
+
+	_, err = tmpl.Claims(claimsTemplateContext{})
+	assert.Error(t, err)
+}
+
+func TestClaimsTemplateCanReferenceSignersVerified(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `{"signers": [{{range $i, $s := .SignersVerified}}{{if $i}},{{end}}"{{$s.Address}}"{{end}}]}`)
+	require.NoError(t, err)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{
+		SignersVerified: []claimsTemplateSigner{{Address: "GABC", Weight: 1}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"GABC"}, claims["signers"])
+}
+
+func TestClaimsTemplateCanRejectUnknownAccounts(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `{{if not .AccountExists}}{{fail "unknown account"}}{{end}}{}`)
+	require.NoError(t, err)
+
+	_, err = tmpl.Claims(claimsTemplateContext{AccountExists: false})
+	require.Error(t, err)
+	var rejected *errClaimsRejected
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "unknown account", rejected.Reason)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{AccountExists: true})
+	require.NoError(t, err)
+	assert.Empty(t, claims)
+}
+
+func TestClaimsTemplateCanShortCircuitOnThreshold(t *testing.T) {
+	tmpl, err := newClaimsTemplate("example.com", `{{if lt .HighThreshold 10}}{{fail "threshold too low"}}{{end}}{"scope": "full"}`)
+	require.NoError(t, err)
+
+	_, err = tmpl.Claims(claimsTemplateContext{HighThreshold: 5})
+	require.Error(t, err)
+	var rejected *errClaimsRejected
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "threshold too low", rejected.Reason)
+
+	claims, err := tmpl.Claims(claimsTemplateContext{HighThreshold: 20})
+	require.NoError(t, err)
+	assert.Equal(t, "full", claims["scope"])
+}
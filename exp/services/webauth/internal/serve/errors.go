@@ -0,0 +1,8 @@
+package serve
+
+import "errors"
+
+var (
+	errInvalidMemo                   = errors.New("memo is not a valid uint64 memo id")
+	errMemoNotAllowedForMuxedAccount = errors.New("memo cannot be combined with a muxed account")
+)
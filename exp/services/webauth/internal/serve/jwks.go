@@ -0,0 +1,19 @@
+package serve
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hcnet/go/support/render/httpjson"
+)
+
+// jwksHandler serves the public half of every signing key a relying party
+// may need to verify a token issued by tokenHandler, including keys that
+// are retired but still within their grace period.
+type jwksHandler struct {
+	SigningKeys *signingKeySet
+}
+
+func (h jwksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	httpjson.Render(w, h.SigningKeys.JWKS(time.Now()), httpjson.JSON)
+}
@@ -0,0 +1,86 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestJWKSHandlerServesPublicKeysOverHTTP(t *testing.T) {
+	retiredAt := time.Now().Add(-time.Minute)
+	set, err := newSigningKeySet([]signingKey{
+		{JWK: testJWK("old"), RetiredAt: &retiredAt},
+		{JWK: testJWK("new")},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	h := jwksHandler{SigningKeys: set}
+	req := httptest.NewRequest(http.MethodGet, "/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+
+	var kids []string
+	for _, k := range jwks.Keys {
+		kids = append(kids, k.KeyID)
+	}
+	assert.ElementsMatch(t, []string{"old", "new"}, kids)
+}
+
+// TestSigningKeySetRotationKeepsOldTokensVerifiable exercises the rotation
+// story end-to-end: a token signed under a key that is then retired must
+// still verify against the public key published in the JWKS, as long as
+// the lookup happens within the configured grace period.
+func TestSigningKeySetRotationKeepsOldTokensVerifiable(t *testing.T) {
+	oldPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	oldJWK := jose.JSONWebKey{Key: oldPriv, KeyID: "old", Algorithm: string(jose.RS256), Use: "sig"}
+	newJWK := jose.JSONWebKey{Key: newPriv, KeyID: "new", Algorithm: string(jose.RS256), Use: "sig"}
+
+	set, err := newSigningKeySet([]signingKey{{JWK: oldJWK}}, time.Hour)
+	require.NoError(t, err)
+
+	jwsOptions := (&jose.SignerOptions{}).WithType("JWT")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: &oldJWK}, jwsOptions)
+	require.NoError(t, err)
+	tokenStr, err := jwt.Signed(signer).Claims(jwt.Claims{Subject: "GABC"}).CompactSerialize()
+	require.NoError(t, err)
+
+	// Rotate: "old" retires in favor of "new".
+	retiredAt := time.Now()
+	require.NoError(t, set.set([]signingKey{
+		{JWK: oldJWK, RetiredAt: &retiredAt},
+		{JWK: newJWK},
+	}))
+	require.Equal(t, "new", set.Current().KeyID)
+
+	// Within the grace period, a relying party fetching the JWKS can still
+	// find the key that signed the already-issued token.
+	jwks := set.JWKS(retiredAt.Add(time.Minute))
+	parsed, err := jwt.ParseSigned(tokenStr)
+	require.NoError(t, err)
+	require.Len(t, parsed.Headers, 1)
+
+	verifyingKeys := jwks.Key(parsed.Headers[0].KeyID)
+	require.Len(t, verifyingKeys, 1)
+
+	var claims jwt.Claims
+	require.NoError(t, parsed.Claims(verifyingKeys[0].Key.(*rsa.PublicKey), &claims))
+	assert.Equal(t, "GABC", claims.Subject)
+}
@@ -0,0 +1,128 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	supportlog "github.com/hcnet/go/support/log"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// signingKey pairs a JWK with the time, if any, it was retired. A nil
+// RetiredAt marks the key currently used to sign new tokens; a
+// signingKeySet must have exactly one such key.
+type signingKey struct {
+	JWK       jose.JSONWebKey
+	RetiredAt *time.Time
+}
+
+func (k signingKey) retired() bool {
+	return k.RetiredAt != nil
+}
+
+// signingKeySet holds the JWT signing keys a tokenHandler may use,
+// supporting zero-downtime rotation: activating a new key starts it
+// signing tokens immediately, while the keys it replaces stay published in
+// the JWKS for GracePeriod so tokens already issued under them keep
+// verifying.
+type signingKeySet struct {
+	GracePeriod time.Duration
+
+	mu   sync.RWMutex
+	keys []signingKey
+}
+
+func newSigningKeySet(keys []signingKey, gracePeriod time.Duration) (*signingKeySet, error) {
+	s := &signingKeySet{GracePeriod: gracePeriod}
+	if err := s.set(keys); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *signingKeySet) set(keys []signingKey) error {
+	var currentCount int
+	for i, k := range keys {
+		if k.JWK.KeyID == "" {
+			return fmt.Errorf("signing key at index %d is missing a kid", i)
+		}
+		if !k.retired() {
+			currentCount++
+		}
+	}
+	if currentCount != 1 {
+		return fmt.Errorf("signing key set must have exactly one active (non-retired) key, got %d", currentCount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	return nil
+}
+
+// Current returns the key used to sign new tokens.
+func (s *signingKeySet) Current() jose.JSONWebKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if !k.retired() {
+			return k.JWK
+		}
+	}
+	panic("signingKeySet: no active signing key, this should be unreachable")
+}
+
+// JWKS renders the public half of every key that has not aged out of its
+// retirement grace period, for publishing at /jwks.json.
+func (s *signingKeySet) JWKS(now time.Time) jose.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := jose.JSONWebKeySet{}
+	for _, k := range s.keys {
+		if k.retired() && now.Sub(*k.RetiredAt) > s.GracePeriod {
+			continue
+		}
+		set.Keys = append(set.Keys, k.JWK.Public())
+	}
+	return set
+}
+
+// Reload replaces the key set by re-reading it from a file containing a
+// JSON-encoded []signingKey. It is safe to call while the service is
+// handling requests, and is intended to be wired up to WatchSIGHUP or a
+// polling file watcher so operators can rotate keys without dropping
+// in-flight requests.
+func (s *signingKeySet) Reload(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading signing keys file: %w", err)
+	}
+	var keys []signingKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("parsing signing keys file: %w", err)
+	}
+	return s.set(keys)
+}
+
+// WatchSIGHUP reloads the key set from path whenever the process receives
+// SIGHUP, logging the outcome. It returns immediately; the reload runs in a
+// background goroutine for the lifetime of the process.
+func (s *signingKeySet) WatchSIGHUP(path string, logger *supportlog.Entry) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	go func() {
+		for range sigs {
+			if err := s.Reload(path); err != nil {
+				logger.WithStack(err).Error(err)
+				continue
+			}
+			logger.Info("Reloaded JWT signing keys after SIGHUP.")
+		}
+	}()
+}
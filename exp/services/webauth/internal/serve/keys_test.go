@@ -0,0 +1,80 @@
+package serve
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func testJWK(kid string) jose.JSONWebKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+func TestNewSigningKeySetRequiresExactlyOneCurrentKey(t *testing.T) {
+	_, err := newSigningKeySet(nil, time.Hour)
+	assert.Error(t, err)
+
+	retiredAt := time.Now()
+	_, err = newSigningKeySet([]signingKey{
+		{JWK: testJWK("retired"), RetiredAt: &retiredAt},
+	}, time.Hour)
+	assert.Error(t, err)
+
+	_, err = newSigningKeySet([]signingKey{
+		{JWK: testJWK("a")},
+		{JWK: testJWK("b")},
+	}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestNewSigningKeySetRequiresKid(t *testing.T) {
+	_, err := newSigningKeySet([]signingKey{
+		{JWK: jose.JSONWebKey{Key: []byte("secret")}},
+	}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestSigningKeySetCurrent(t *testing.T) {
+	retiredAt := time.Now()
+	set, err := newSigningKeySet([]signingKey{
+		{JWK: testJWK("old"), RetiredAt: &retiredAt},
+		{JWK: testJWK("new")},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, "new", set.Current().KeyID)
+}
+
+func TestSigningKeySetJWKSHonorsGracePeriod(t *testing.T) {
+	now := time.Now()
+	retiredAt := now.Add(-2 * time.Hour)
+	set, err := newSigningKeySet([]signingKey{
+		{JWK: testJWK("old"), RetiredAt: &retiredAt},
+		{JWK: testJWK("new")},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	jwks := set.JWKS(now)
+	var kids []string
+	for _, k := range jwks.Keys {
+		kids = append(kids, k.KeyID)
+	}
+	assert.ElementsMatch(t, []string{"new"}, kids)
+
+	jwksWithinGrace := set.JWKS(retiredAt.Add(time.Minute))
+	kids = nil
+	for _, k := range jwksWithinGrace.Keys {
+		kids = append(kids, k.KeyID)
+	}
+	assert.ElementsMatch(t, []string{"old", "new"}, kids)
+}
@@ -0,0 +1,111 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hcnet/go/clients/auroraclient"
+	"github.com/hcnet/go/keypair"
+	supportlog "github.com/hcnet/go/support/log"
+)
+
+// Options configures the webauth SEP-10 service: the SEP-10 challenge and
+// token endpoints, and the JWKS document relying parties use to verify
+// issued tokens.
+type Options struct {
+	Logger                      *supportlog.Entry
+	AuroraClient                auroraclient.ClientInterface
+	AuroraURL                   string
+	NetworkPassphrase           string
+	SigningAddresses            []*keypair.FromAddress
+	SigningKey                  *keypair.Full
+	SigningKeys                 []signingKey
+	SigningKeysGracePeriod      time.Duration
+	SigningKeysReloadPath       string
+	ChallengeExpiresIn          time.Duration
+	JWTIssuer                   string
+	JWTExpiresIn                time.Duration
+	AllowAccountsThatDoNotExist bool
+	Domain                      string
+	HomeDomains                 []string
+	// ClaimsTemplates holds a text/template, keyed by home domain, used to
+	// build a token's private claims. A home domain with no entry gets
+	// defaultClaimsTemplateText.
+	ClaimsTemplates map[string]string
+}
+
+// Handler builds the http.Handler that serves the SEP-10 challenge and
+// token endpoints, plus the JWKS document used to verify issued tokens.
+func Handler(opts Options) (http.Handler, error) {
+	signingKeys, err := newSigningKeySet(opts.SigningKeys, opts.SigningKeysGracePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("configuring JWT signing keys: %w", err)
+	}
+	if opts.SigningKeysReloadPath != "" {
+		signingKeys.WatchSIGHUP(opts.SigningKeysReloadPath, opts.Logger)
+	}
+
+	claimsTemplates := map[string]*claimsTemplate{}
+	for _, homeDomain := range opts.HomeDomains {
+		text := opts.ClaimsTemplates[homeDomain]
+		if text == "" {
+			text = defaultClaimsTemplateText
+		}
+		tmpl, err := newClaimsTemplate(homeDomain, text)
+		if err != nil {
+			return nil, err
+		}
+		claimsTemplates[homeDomain] = tmpl
+	}
+
+	challenge := challengeHandler{
+		Logger:             opts.Logger,
+		NetworkPassphrase:  opts.NetworkPassphrase,
+		SigningKey:         opts.SigningKey,
+		ChallengeExpiresIn: opts.ChallengeExpiresIn,
+		Domain:             opts.Domain,
+		HomeDomains:        opts.HomeDomains,
+	}
+	token := tokenHandler{
+		Logger:                      opts.Logger,
+		AuroraClient:                opts.AuroraClient,
+		NetworkPassphrase:           opts.NetworkPassphrase,
+		SigningAddresses:            opts.SigningAddresses,
+		SigningKeys:                 signingKeys,
+		JWTIssuer:                   opts.JWTIssuer,
+		JWTExpiresIn:                opts.JWTExpiresIn,
+		AllowAccountsThatDoNotExist: opts.AllowAccountsThatDoNotExist,
+		Domain:                      opts.Domain,
+		HomeDomains:                 opts.HomeDomains,
+		ClaimsTemplates:             claimsTemplates,
+	}
+	jwks := jwksHandler{SigningKeys: signingKeys}
+
+	if err := logStartupBanner(opts, signingKeys); err != nil {
+		return nil, fmt.Errorf("logging startup banner: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth", authMethodHandler{Get: challenge, Post: token})
+	mux.Handle("/jwks.json", jwks)
+	return mux, nil
+}
+
+// authMethodHandler dispatches the shared /auth endpoint: a GET requests a
+// challenge transaction, a POST exchanges a signed challenge for a JWT.
+type authMethodHandler struct {
+	Get  http.Handler
+	Post http.Handler
+}
+
+func (h authMethodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.Get.ServeHTTP(w, r)
+	case http.MethodPost:
+		h.Post.ServeHTTP(w, r)
+	default:
+		badRequest.Render(w)
+	}
+}
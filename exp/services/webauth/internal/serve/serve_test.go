@@ -0,0 +1,66 @@
+package serve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hcnet/go/clients/auroraclient"
+	hProtocol "github.com/hcnet/go/protocols/aurora"
+	supportlog "github.com/hcnet/go/support/log"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const testNetworkPassphrase = "Test SDF Network ; September 2015"
+
+func testHandlerOptions() Options {
+	client := &auroraclient.MockClient{}
+	client.On("Root").Return(hProtocol.Root{NetworkPassphrase: testNetworkPassphrase}, nil)
+
+	return Options{
+		Logger:                 supportlog.New(),
+		AuroraClient:           client,
+		AuroraURL:              "https://aurora-testnet.hcnet.org",
+		NetworkPassphrase:      testNetworkPassphrase,
+		Domain:                 "example.com",
+		HomeDomains:            []string{"example.com"},
+		JWTIssuer:              "https://example.com",
+		JWTExpiresIn:           5 * time.Minute,
+		SigningKeys:            []signingKey{{JWK: testJWK("current")}},
+		SigningKeysGracePeriod: time.Hour,
+	}
+}
+
+// TestHandlerServesJWKS wires up the full Options -> Handler() path and
+// confirms /jwks.json is reachable and reflects the configured signing
+// keys, rather than only testing jwksHandler in isolation.
+func TestHandlerServesJWKS(t *testing.T) {
+	handler, err := Handler(testHandlerOptions())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	require.Equal(t, "current", jwks.Keys[0].KeyID)
+}
+
+// TestHandlerFailsFastOnNetworkPassphraseMismatch confirms the startup
+// banner's cross-check between the configured NetworkPassphrase and the
+// one Aurora actually reports aborts wiring rather than serving requests
+// against a misconfigured pair.
+func TestHandlerFailsFastOnNetworkPassphraseMismatch(t *testing.T) {
+	opts := testHandlerOptions()
+	opts.NetworkPassphrase = "Public Global Hcnet Network ; September 2015"
+
+	_, err := Handler(opts)
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -11,21 +12,33 @@ import (
 	supportlog "github.com/hcnet/go/support/log"
 	"github.com/hcnet/go/support/render/httpjson"
 	"github.com/hcnet/go/txnbuild"
+	"github.com/hcnet/go/xdr"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
+// readChallengeTx, verifyChallengeTxThreshold, and verifyChallengeTxSigners
+// are package variables, rather than direct calls to txnbuild, purely so
+// tests can substitute them to exercise tokenHandler's logic without needing
+// a real signed challenge transaction for every case.
+var (
+	readChallengeTx            = txnbuild.ReadChallengeTx
+	verifyChallengeTxThreshold = txnbuild.VerifyChallengeTxThreshold
+	verifyChallengeTxSigners   = txnbuild.VerifyChallengeTxSigners
+)
+
 type tokenHandler struct {
 	Logger                      *supportlog.Entry
-	AuroraClient               auroraclient.ClientInterface
+	AuroraClient                auroraclient.ClientInterface
 	NetworkPassphrase           string
 	SigningAddresses            []*keypair.FromAddress
-	JWK                         jose.JSONWebKey
+	SigningKeys                 *signingKeySet
 	JWTIssuer                   string
 	JWTExpiresIn                time.Duration
 	AllowAccountsThatDoNotExist bool
 	Domain                      string
 	HomeDomains                 []string
+	ClaimsTemplates             map[string]*claimsTemplate
 }
 
 type tokenRequest struct {
@@ -54,7 +67,7 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		homeDomain      string
 	)
 	for _, s := range h.SigningAddresses {
-		tx, clientAccountID, homeDomain, err = txnbuild.ReadChallengeTx(req.Transaction, s.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains)
+		tx, clientAccountID, homeDomain, err = readChallengeTx(req.Transaction, s.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains)
 		if err == nil {
 			signingAddress = s
 			break
@@ -69,6 +82,23 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A client identifies either as a muxed (M...) account supplied
+	// directly, or as a G... account with a memo that the challenge handler
+	// folded into the equivalent M... account. Either way, the client
+	// account ID on the challenge transaction is what the signature
+	// actually protects, so decoding it here is enough to recover the
+	// underlying G... account for the rest of verification.
+	var muxedAccount *xdr.MuxedAccount
+	if strings.HasPrefix(clientAccountID, "M") {
+		ma, err := xdr.AddressToMuxedAccount(clientAccountID)
+		if err != nil {
+			badRequest.Render(w)
+			return
+		}
+		muxedAccount = &ma
+		clientAccountID = ma.ToAccountId().Address()
+	}
+
 	hash, err := tx.HashHex(h.NetworkPassphrase)
 	if err != nil {
 		h.Logger.Ctx(ctx).WithStack(err).Error(err)
@@ -100,10 +130,11 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var signersVerified []string
+	var clientSignerSummary txnbuild.SignerSummary
 	if clientAccountExists {
 		requiredThreshold := txnbuild.Threshold(clientAccount.Thresholds.HighThreshold)
-		clientSignerSummary := clientAccount.SignerSummary()
-		signersVerified, err = txnbuild.VerifyChallengeTxThreshold(req.Transaction, signingAddress.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains, requiredThreshold, clientSignerSummary)
+		clientSignerSummary = clientAccount.SignerSummary()
+		signersVerified, err = verifyChallengeTxThreshold(req.Transaction, signingAddress.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains, requiredThreshold, clientSignerSummary)
 		if err != nil {
 			l.
 				WithField("signersCount", len(clientSignerSummary)).
@@ -119,7 +150,7 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			unauthorized.Render(w)
 			return
 		}
-		signersVerified, err = txnbuild.VerifyChallengeTxSigners(req.Transaction, signingAddress.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains, clientAccountID)
+		signersVerified, err = verifyChallengeTxSigners(req.Transaction, signingAddress.Address(), h.NetworkPassphrase, h.Domain, h.HomeDomains, clientAccountID)
 		if err != nil {
 			l.Infof("Failed to verify with account master key as signer.")
 			unauthorized.Render(w)
@@ -131,9 +162,10 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		WithField("signers", strings.Join(signersVerified, ",")).
 		Infof("Successfully verified challenge transaction.")
 
+	signingJWK := h.SigningKeys.Current()
 	jwsOptions := &jose.SignerOptions{}
 	jwsOptions.WithType("JWT")
-	jws, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(h.JWK.Algorithm), Key: h.JWK.Key}, jwsOptions)
+	jws, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(signingJWK.Algorithm), Key: &signingJWK}, jwsOptions)
 	if err != nil {
 		l.WithStack(err).Error(err)
 		serverError.Render(w)
@@ -143,11 +175,44 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	issuedAt := time.Unix(tx.Timebounds().MinTime, 0)
 	claims := jwt.Claims{
 		Issuer:   h.JWTIssuer,
-		Subject:  clientAccountID,
+		Subject:  challengeSubject(clientAccountID, muxedAccount),
 		IssuedAt: jwt.NewNumericDate(issuedAt),
 		Expiry:   jwt.NewNumericDate(issuedAt.Add(h.JWTExpiresIn)),
 	}
-	tokenStr, err := jwt.Signed(jws).Claims(claims).CompactSerialize()
+
+	tmpl := h.ClaimsTemplates[homeDomain]
+	if tmpl == nil {
+		tmpl, err = newClaimsTemplate(homeDomain, defaultClaimsTemplateText)
+		if err != nil {
+			l.WithStack(err).Error(err)
+			serverError.Render(w)
+			return
+		}
+	}
+	privateClaims, err := tmpl.Claims(claimsTemplateContext{
+		AccountID:       clientAccountID,
+		HomeDomain:      homeDomain,
+		SigningAddress:  signingAddress.Address(),
+		SignersVerified: verifiedSignerWeights(signersVerified, clientSignerSummary),
+		AccountExists:   clientAccountExists,
+		LowThreshold:    clientAccount.Thresholds.LowThreshold,
+		MedThreshold:    clientAccount.Thresholds.MedThreshold,
+		HighThreshold:   clientAccount.Thresholds.HighThreshold,
+		TransactionHash: hash,
+	})
+	if err != nil {
+		var rejected *errClaimsRejected
+		if errors.As(err, &rejected) {
+			l.WithField("reason", rejected.Reason).Info("Claims template rejected issuance.")
+			unauthorized.Render(w)
+			return
+		}
+		l.WithStack(err).Error(err)
+		serverError.Render(w)
+		return
+	}
+
+	tokenStr, err := jwt.Signed(jws).Claims(claims).Claims(privateClaims).CompactSerialize()
 	if err != nil {
 		l.WithStack(err).Error(err)
 		serverError.Render(w)
@@ -159,3 +224,30 @@ func (h tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	httpjson.Render(w, res, httpjson.JSON)
 }
+
+// challengeSubject builds the JWT `sub` claim for a verified challenge. A
+// muxed account, whether supplied by the client directly or derived from a
+// memo by the challenge handler, is reported as-is, since it already
+// carries its own sub-account identifier.
+func challengeSubject(clientAccountID string, muxedAccount *xdr.MuxedAccount) string {
+	if muxedAccount != nil {
+		return muxedAccount.Address()
+	}
+	return clientAccountID
+}
+
+// verifiedSignerWeights pairs the signers that met the challenge's
+// threshold with their on-chain weight, for claims templates that want to
+// expose which keys authorized a token. summary is nil when the client
+// account does not exist, in which case the verified signer is the
+// account's master key and its weight is not meaningful on-chain.
+func verifiedSignerWeights(signersVerified []string, summary txnbuild.SignerSummary) []claimsTemplateSigner {
+	signers := make([]claimsTemplateSigner, len(signersVerified))
+	for i, address := range signersVerified {
+		signers[i] = claimsTemplateSigner{
+			Address: address,
+			Weight:  summary[address],
+		}
+	}
+	return signers
+}
